@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"wg-overlay/pkg/wireguard"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// runConnect lets a host outside the cluster join the overlay: it brings up
+// a local WireGuard interface, watches Nodes through the same
+// controller-runtime client used by WireguardNodeReconciler, and keeps its
+// peer list in sync with every Node that advertises a public key.
+func runConnect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	allowedIP := fs.String("allowed-ip", "", "overlay IP to assign to this host inside the overlay CIDR (required)")
+	iface := fs.String("interface", "wg-overlay", "name of the local WireGuard interface to create")
+	keyFile := fs.String("private-key-file", "wg-overlay.key", "path to a WireGuard private key; generated and persisted here if absent")
+	backendKind := fs.String("wg-backend", "kernel", "wireguard backend to use: kernel|userspace")
+	userspaceBinary := fs.String("userspace-binary", "", "path to a wireguard-go or boringtun binary, required when --wg-backend=userspace")
+	listenPort := fs.Int("listen-port", 0, "UDP port to listen on (0 lets WireGuard pick one)")
+	keepalive := fs.Duration("persistent-keepalive", wireguard.DefaultPersistentKeepalive, "persistent keepalive interval to advertise to peers")
+	resyncPeriod := fs.Duration("resync-period", 30*time.Second, "how often to re-list nodes and resync the local peer set")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *allowedIP == "" {
+		return fmt.Errorf("--allowed-ip is required")
+	}
+
+	privateKey, err := wireguard.LoadOrGeneratePrivateKey(*keyFile)
+	if err != nil {
+		return fmt.Errorf("loading private key: %w", err)
+	}
+
+	if *backendKind == "" || *backendKind == "kernel" {
+		if err := wireguard.EnsureKernelInterface(*iface); err != nil {
+			return fmt.Errorf("creating local interface: %w", err)
+		}
+	}
+
+	backend, err := wireguard.NewBackend(*backendKind, *iface, *userspaceBinary)
+	if err != nil {
+		return fmt.Errorf("setting up wireguard backend: %w", err)
+	}
+	defer backend.Close()
+
+	if err := backend.ConfigureDevice(wgtypes.Config{PrivateKey: &privateKey, ListenPort: listenPort}); err != nil {
+		return fmt.Errorf("configuring local device: %w", err)
+	}
+
+	if err := wireguard.AssignAddress(*iface, *allowedIP); err != nil {
+		return fmt.Errorf("assigning overlay address: %w", err)
+	}
+
+	restCfg, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	cl, err := client.New(restCfg, client.Options{})
+	if err != nil {
+		return fmt.Errorf("building kubernetes client: %w", err)
+	}
+
+	// connect deliberately does not publish its own public key/allowed-ip
+	// back onto the cluster, so in-cluster reconcilers have nothing to add
+	// as a returning peer: this client is peer-only. Closing that loop needs
+	// either a synthetic Node registration or a new OverlayClient CRD with
+	// its own controller, both of which are a materially bigger change than
+	// this command's "dial out to the cluster" scope. Until one of those
+	// lands, pair this client with a static EndpointAnnotationName
+	// annotation on a real Node to be dialable from inside the cluster.
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	sync := func() error {
+		var nodes v1.NodeList
+		if err := cl.List(ctx, &nodes); err != nil {
+			return fmt.Errorf("listing nodes: %w", err)
+		}
+
+		desired := make([]wgtypes.PeerConfig, 0, len(nodes.Items))
+		for _, n := range nodes.Items {
+			if _, ok := n.Annotations[wireguard.PublicKeyAnnotationName]; !ok {
+				continue
+			}
+			peer, err := wireguard.FromNode(n, nil, *keepalive)
+			if err != nil {
+				log.Printf("skipping node %s: %v", n.Name, err)
+				continue
+			}
+			desired = append(desired, wgtypes.PeerConfig{
+				PublicKey:                   peer.PublicKey,
+				AllowedIPs:                  peer.AllowedIPs,
+				Endpoint:                    peer.Endpoint,
+				PersistentKeepaliveInterval: &peer.PersistentKeepaliveInterval,
+			})
+		}
+
+		return wireguard.SyncPeers(backend, desired)
+	}
+
+	if err := sync(); err != nil {
+		log.Printf("initial peer sync failed: %v", err)
+	}
+
+	ticker := time.NewTicker(*resyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := sync(); err != nil {
+				log.Printf("peer resync failed: %v", err)
+			}
+		}
+	}
+}
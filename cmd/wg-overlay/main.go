@@ -0,0 +1,30 @@
+// Command wg-overlay is the CLI entrypoint for ad-hoc interaction with the
+// overlay from outside the cluster, in addition to the in-cluster
+// controller built from pkg/controllers.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: wg-overlay <connect> [flags]")
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "connect":
+		err = runConnect(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wg-overlay %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import "sync"
+
+// peerState is the last configuration applied for a node's peer. fingerprint
+// encodes everything that would require a ConfigureDevice call if it
+// changed (see wireguard.Fingerprint: the public key, allowed IPs,
+// endpoint, pre-shared key, and keepalive interval), while pubKey alone is
+// kept for reverse lookups.
+type peerState struct {
+	pubKey      string
+	fingerprint string
+}
+
+// peerCache is a bidirectional, concurrency-safe mapping between WireGuard
+// public keys and the names of the Nodes that own them. It is shared
+// between WireguardNodeReconciler and PeerHealthMonitor so both can resolve
+// a peer back to its owning Node without keeping a second source of truth.
+type peerCache struct {
+	mu       sync.RWMutex
+	byNode   map[string]peerState // node name -> last applied peer state
+	byPeer   map[string]string    // public key -> node name
+	hydrated bool                 // set once hydrateCache has populated the cache from live Nodes
+}
+
+func newPeerCache() *peerCache {
+	return &peerCache{
+		byNode: make(map[string]peerState),
+		byPeer: make(map[string]string),
+	}
+}
+
+// put records that nodeName's peer was last configured with the given
+// public key and fingerprint (see peerState).
+func (c *peerCache) put(nodeName, pubKey, fingerprint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.byNode[nodeName]; ok {
+		delete(c.byPeer, old.pubKey)
+	}
+	c.byNode[nodeName] = peerState{pubKey: pubKey, fingerprint: fingerprint}
+	c.byPeer[pubKey] = nodeName
+}
+
+func (c *peerCache) del(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, ok := c.byNode[nodeName]; ok {
+		delete(c.byPeer, state.pubKey)
+	}
+	delete(c.byNode, nodeName)
+}
+
+func (c *peerCache) get(nodeName string) (peerState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.byNode[nodeName]
+	return state, ok
+}
+
+// getNode resolves a public key back to the name of the Node that claims
+// it.
+func (c *peerCache) getNode(pubKey string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name, ok := c.byPeer[pubKey]
+	return name, ok
+}
+
+func (c *peerCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byNode = make(map[string]peerState)
+	c.byPeer = make(map[string]string)
+}
+
+func (c *peerCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.byNode)
+}
+
+// markHydrated records that hydrateCache has populated the cache from the
+// live Node list at least once. Callers that would otherwise treat an empty
+// cache as "nothing claims this peer" (e.g. PeerHealthMonitor's GC pass)
+// must check isHydrated first, since an empty cache before the first
+// hydration just means startup hasn't listed Nodes yet, not that no peers
+// are claimed.
+func (c *peerCache) markHydrated() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hydrated = true
+}
+
+func (c *peerCache) isHydrated() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hydrated
+}
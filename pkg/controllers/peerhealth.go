@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wg-overlay/pkg/wireguard"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultHealthCheckInterval is used when PeerHealthMonitor.Interval is left
+// unset.
+const defaultHealthCheckInterval = 15 * time.Second
+
+// defaultGCTTL is used when PeerHealthMonitor.GCTTL is left unset.
+const defaultGCTTL = 10 * time.Minute
+
+var (
+	peerLastHandshakeSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wg_overlay_peer_last_handshake_seconds",
+		Help: "Unix time of the last WireGuard handshake with a peer.",
+	}, []string{"node"})
+	peerRxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wg_overlay_peer_rx_bytes",
+		Help: "Total bytes received from a peer.",
+	}, []string{"node"})
+	peerTxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wg_overlay_peer_tx_bytes",
+		Help: "Total bytes sent to a peer.",
+	}, []string{"node"})
+	peerUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wg_overlay_peer_up",
+		Help: "1 if a peer has a non-nil endpoint and a recent handshake, 0 otherwise.",
+	}, []string{"node"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(peerLastHandshakeSeconds, peerRxBytes, peerTxBytes, peerUp)
+}
+
+// PeerHealthMonitor runs alongside WireguardNodeReconciler, exposing
+// Prometheus metrics for every known peer and emitting Node Events when a
+// peer transitions between healthy and unhealthy. It shares the
+// reconciler's peerCache to attribute peers back to Node names.
+//
+// A peer is considered "up" if it has a non-nil endpoint and has
+// handshaked within the last 3x wireguard.DefaultPersistentKeepalive (see
+// wireguard.HandshakeFresh), mirroring netmaker's HasPeerConnected check.
+type PeerHealthMonitor struct {
+	client.Client
+	WgClient wireguard.Backend
+	Recorder record.EventRecorder
+	cache    *peerCache
+
+	// Interval controls how often peers are inspected.
+	Interval time.Duration
+
+	// GC, when true, removes peers from the device that no longer appear
+	// in any Node's public key annotation and whose last handshake exceeds
+	// GCTTL. This catches peers that leaked because the controller missed
+	// a Node delete event.
+	GC    bool
+	GCTTL time.Duration
+
+	healthy map[wgtypes.Key]bool
+	// labeled tracks the Node names currently carrying a set of
+	// "wg_overlay_peer_*" label values, so a peer that stops being claimed
+	// (Node deleted, annotation removed, GC'd) has its stale series removed
+	// instead of reporting its last value forever.
+	labeled map[string]bool
+}
+
+// NewPeerHealthMonitor constructs a PeerHealthMonitor that shares r's peer
+// cache, so metrics and events are attributed to the same Node names the
+// reconciler has already resolved.
+func NewPeerHealthMonitor(r *WireguardNodeReconciler, recorder record.EventRecorder) *PeerHealthMonitor {
+	if r.cache == nil {
+		r.cache = newPeerCache()
+	}
+	return &PeerHealthMonitor{
+		Client:   r.Client,
+		WgClient: r.WgClient,
+		Recorder: recorder,
+		cache:    r.cache,
+	}
+}
+
+func (m *PeerHealthMonitor) Start(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	if m.cache == nil {
+		m.cache = newPeerCache()
+	}
+	if m.healthy == nil {
+		m.healthy = make(map[wgtypes.Key]bool)
+	}
+	if m.labeled == nil {
+		m.labeled = make(map[string]bool)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+func (m *PeerHealthMonitor) check(ctx context.Context) {
+	logger := log.FromContext(ctx)
+
+	device, err := m.WgClient.Device()
+	if err != nil {
+		logger.Error(err, "failed to fetch wireguard device for health check")
+		return
+	}
+
+	claimed := make(map[string]bool, len(device.Peers))
+	current := make(map[string]bool, len(device.Peers))
+	for _, peer := range device.Peers {
+		pubKey := peer.PublicKey.String()
+		nodeName, ok := m.cache.getNode(pubKey)
+		if !ok {
+			continue
+		}
+		claimed[pubKey] = true
+		current[nodeName] = true
+
+		up := peer.Endpoint != nil && wireguard.HandshakeFresh(peer)
+
+		peerLastHandshakeSeconds.WithLabelValues(nodeName).Set(float64(peer.LastHandshakeTime.Unix()))
+		peerRxBytes.WithLabelValues(nodeName).Set(float64(peer.ReceiveBytes))
+		peerTxBytes.WithLabelValues(nodeName).Set(float64(peer.TransmitBytes))
+		if up {
+			peerUp.WithLabelValues(nodeName).Set(1)
+		} else {
+			peerUp.WithLabelValues(nodeName).Set(0)
+		}
+
+		if wasUp, known := m.healthy[peer.PublicKey]; !known || wasUp != up {
+			m.healthy[peer.PublicKey] = up
+			m.recordTransition(ctx, nodeName, up)
+		}
+	}
+
+	m.pruneMetrics(current)
+
+	if m.GC {
+		if !m.cache.isHydrated() {
+			logger.Info("skipping gc: peer cache has not been hydrated yet")
+		} else {
+			m.gc(ctx, device, claimed)
+		}
+	}
+}
+
+// pruneMetrics deletes the "wg_overlay_peer_*" label series for any Node
+// that previously had metrics set but is no longer claimed by a peer on
+// this tick, so departed peers stop reporting stale values instead of
+// lingering in the metric indefinitely.
+func (m *PeerHealthMonitor) pruneMetrics(current map[string]bool) {
+	for nodeName := range m.labeled {
+		if current[nodeName] {
+			continue
+		}
+		peerLastHandshakeSeconds.DeleteLabelValues(nodeName)
+		peerRxBytes.DeleteLabelValues(nodeName)
+		peerTxBytes.DeleteLabelValues(nodeName)
+		peerUp.DeleteLabelValues(nodeName)
+		delete(m.labeled, nodeName)
+	}
+	for nodeName := range current {
+		m.labeled[nodeName] = true
+	}
+}
+
+func (m *PeerHealthMonitor) recordTransition(ctx context.Context, nodeName string, up bool) {
+	logger := log.FromContext(ctx)
+
+	var node v1.Node
+	if err := m.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+		logger.Error(err, "failed to fetch node to record peer health event", "node", nodeName)
+		return
+	}
+
+	if up {
+		m.Recorder.Event(&node, v1.EventTypeNormal, "PeerConnected", "WireGuard peer is up")
+	} else {
+		m.Recorder.Event(&node, v1.EventTypeWarning, "PeerDisconnected", "WireGuard peer has no recent handshake")
+	}
+}
+
+// gc removes peers from the device that are not claimed by any known Node
+// and whose last handshake is older than GCTTL.
+func (m *PeerHealthMonitor) gc(ctx context.Context, device *wgtypes.Device, claimed map[string]bool) {
+	logger := log.FromContext(ctx)
+
+	ttl := m.GCTTL
+	if ttl <= 0 {
+		ttl = defaultGCTTL
+	}
+
+	var stale []wgtypes.PeerConfig
+	for _, peer := range device.Peers {
+		if claimed[peer.PublicKey.String()] {
+			continue
+		}
+		if !peer.LastHandshakeTime.IsZero() && time.Since(peer.LastHandshakeTime) <= ttl {
+			continue
+		}
+		stale = append(stale, wgtypes.PeerConfig{PublicKey: peer.PublicKey, Remove: true})
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	if err := m.WgClient.ConfigureDevice(wgtypes.Config{Peers: stale}); err != nil {
+		logger.Error(fmt.Errorf("gc ConfigureDevice failed: %w", err), "failed to remove stale peers")
+		return
+	}
+	logger.Info("garbage collected stale peers", "count", len(stale))
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 	"wg-overlay/pkg/overlay"
 	"wg-overlay/pkg/wireguard"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 
-	"golang.zx2c4.com/wireguard/wgctrl"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	v1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -17,37 +17,47 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// defaultDiscoveryInterval is used when overlay.Config.DiscoveryInterval is
+// left unset.
+const defaultDiscoveryInterval = 30 * time.Second
+
 type WireguardNodeReconciler struct {
 	client.Client
 	overlay.Config
+	// WgDevice seeds the initial device snapshot at construction time. Once
+	// the reconciler is running, both Reconcile (called from multiple
+	// worker goroutines) and the discovery loop started by Start read and
+	// refresh this state concurrently, so all access after construction
+	// must go through device()/setDevice() rather than this field
+	// directly.
 	WgDevice *wgtypes.Device
-	WgClient *wgctrl.Client
-	cache    map[string]string
-	mu       sync.RWMutex
+	WgClient wireguard.Backend
+	cache    *peerCache
+
+	deviceMu sync.RWMutex
 	//Scheme *runtime.Scheme
 }
 
-func (r *WireguardNodeReconciler) put(key, val string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.cache[key] = val
-}
-func (r *WireguardNodeReconciler) del(key string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	delete(r.cache, key)
+// device returns the most recently known WireGuard device state.
+func (r *WireguardNodeReconciler) device() *wgtypes.Device {
+	r.deviceMu.RLock()
+	defer r.deviceMu.RUnlock()
+	return r.WgDevice
 }
 
-func (r *WireguardNodeReconciler) get(key string) (string, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	val, ok := r.cache[key]
-	return val, ok
+// setDevice atomically replaces the known WireGuard device state.
+func (r *WireguardNodeReconciler) setDevice(d *wgtypes.Device) {
+	r.deviceMu.Lock()
+	defer r.deviceMu.Unlock()
+	r.WgDevice = d
 }
 
 func (r *WireguardNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
-	if len(r.cache) == 0 {
+	if r.cache == nil {
+		r.cache = newPeerCache()
+	}
+	if r.cache.len() == 0 {
 		// cache should contain at least the node it is running on so if cache is empty
 		// then it must be hydrated
 		r.hydrateCache(ctx)
@@ -58,10 +68,10 @@ func (r *WireguardNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			logger.Error(err, "unable to fetch node")
 			return ctrl.Result{}, err
 		}
-		if pubKey, ok := r.get(req.Name); ok {
-			key, err := wgtypes.ParseKey(pubKey)
+		if state, ok := r.cache.get(req.Name); ok {
+			key, err := wgtypes.ParseKey(state.pubKey)
 			if err != nil {
-				logger.Error(err, "failed to parse public key", "key", pubKey)
+				logger.Error(err, "failed to parse public key", "key", state.pubKey)
 				return ctrl.Result{}, err
 			}
 			peerToDelete := &wgtypes.Peer{PublicKey: key}
@@ -71,7 +81,7 @@ func (r *WireguardNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 				logger.Error(err, "failed to delete peer")
 				return ctrl.Result{}, err
 			}
-			r.del(req.Name)
+			r.cache.del(req.Name)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -87,12 +97,22 @@ func (r *WireguardNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			logger.Error(err, "unable to annotate node")
 		}
 	} else {
-		peer, err := wireguard.FromNode(node)
+		peer, err := wireguard.FromNode(node, r.knownPeers(), r.keepalive())
 		if err != nil {
 			logger.Error(err, "failed to get peer from node")
 			return ctrl.Result{}, nil
 		}
-		if pubKey, ok := r.get(node.Name); ok && pubKey == peer.PublicKey.String() {
+		psk, err := r.presharedKeyFor(ctx, node)
+		if err != nil {
+			logger.Error(err, "failed to load preshared key", "node", node.Name)
+			return ctrl.Result{}, err
+		}
+		if psk != nil {
+			peer.PresharedKey = *psk
+		}
+
+		fingerprint := wireguard.Fingerprint(peer)
+		if state, ok := r.cache.get(node.Name); ok && state.fingerprint == fingerprint {
 			logger.Info("node already configured as peer", "publickey", peer.PublicKey.String())
 			return ctrl.Result{}, nil
 		}
@@ -102,12 +122,56 @@ func (r *WireguardNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			return ctrl.Result{}, err
 		}
 		logger.Info("successfully added peer", "peer", *peer)
-		r.put(node.Name, peer.PublicKey.String())
+		r.cache.put(node.Name, peer.PublicKey.String(), fingerprint)
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// knownPeers snapshots the local device's peers keyed by public key, for use
+// when resolving which endpoint to dial for a given node.
+func (r *WireguardNodeReconciler) knownPeers() map[wgtypes.Key]wgtypes.Peer {
+	device := r.device()
+	peers := make(map[wgtypes.Key]wgtypes.Peer, len(device.Peers))
+	for _, p := range device.Peers {
+		peers[p.PublicKey] = p
+	}
+	return peers
+}
+
+// keepalive returns the configured persistent keepalive interval, falling
+// back to wireguard.DefaultPersistentKeepalive when unset.
+func (r *WireguardNodeReconciler) keepalive() time.Duration {
+	if r.PersistentKeepalive > 0 {
+		return r.PersistentKeepalive
+	}
+	return wireguard.DefaultPersistentKeepalive
+}
+
+// presharedKeyFor resolves the pre-shared key for a peer node, if one is
+// configured via wireguard.PresharedKeyAnnotationName. It returns a nil key
+// and nil error when the node carries no such annotation.
+func (r *WireguardNodeReconciler) presharedKeyFor(ctx context.Context, n v1.Node) (*wgtypes.Key, error) {
+	secretName, ok := n.Annotations[wireguard.PresharedKeyAnnotationName]
+	if !ok {
+		return nil, nil
+	}
+
+	var secret v1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: secretName}, &secret); err != nil {
+		return nil, fmt.Errorf("fetching preshared key secret %s/%s: %w", r.Namespace, secretName, err)
+	}
+	raw, ok := secret.Data["psk"]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s missing %q data key", r.Namespace, secretName, "psk")
+	}
+	key, err := wgtypes.NewKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing preshared key from secret %s/%s: %w", r.Namespace, secretName, err)
+	}
+	return &key, nil
+}
+
 func (r *WireguardNodeReconciler) Annotate(n *v1.Node) (bool, error) {
 	update := false
 	if ip, ok := n.Annotations[wireguard.IPAnnotationName]; !ok || ip != r.OverlayIP {
@@ -115,7 +179,7 @@ func (r *WireguardNodeReconciler) Annotate(n *v1.Node) (bool, error) {
 		update = true
 	}
 
-	pubKey := r.WgDevice.PublicKey.String()
+	pubKey := r.device().PublicKey.String()
 	if pub, ok := n.Annotations[wireguard.PublicKeyAnnotationName]; !ok || pub != pubKey {
 		n.Annotations[wireguard.PublicKeyAnnotationName] = pubKey
 		update = true
@@ -123,24 +187,52 @@ func (r *WireguardNodeReconciler) Annotate(n *v1.Node) (bool, error) {
 	return update, nil
 }
 
+// ReconcilePeer pushes peer's current config to the local WireGuard device,
+// going through SyncPeers so the same full-desired-state diffing wg-overlay
+// connect uses is applied here: the device's existing peers (minus peer
+// itself) are carried forward untouched and peer is added, updated, or (if
+// isDelete) left out of the desired set entirely.
+//
+// desired is built from a fresh Device() call rather than the r.device()
+// snapshot: that snapshot is refreshed by the discovery loop on
+// DiscoveryInterval (default 30s) and here before ConfigureDevice is called,
+// so it can still be missing peers added by a ReconcilePeer call for a
+// different Node earlier in the same window. Reading the live device avoids SyncPeers mistaking
+// those peers for ones that should be removed.
 func (r *WireguardNodeReconciler) ReconcilePeer(peer *wgtypes.Peer, isDelete bool) error {
-	cfg := wgtypes.Config{
-		Peers: []wgtypes.PeerConfig{
-			{
-				PublicKey:  peer.PublicKey,
-				AllowedIPs: peer.AllowedIPs,
-				Endpoint:   peer.Endpoint,
-			},
-		},
+	device, err := r.WgClient.Device()
+	if err != nil {
+		return fmt.Errorf("fetching wireguard device: %w", err)
 	}
-	if isDelete {
-		for i := range cfg.Peers {
-			cfg.Peers[i].Remove = true
+	r.setDevice(device)
+
+	existingPeers := device.Peers
+	desired := make([]wgtypes.PeerConfig, 0, len(existingPeers))
+	for i := range existingPeers {
+		existing := existingPeers[i]
+		if existing.PublicKey == peer.PublicKey {
+			continue
 		}
+		desired = append(desired, wgtypes.PeerConfig{
+			PublicKey:                   existing.PublicKey,
+			AllowedIPs:                  existing.AllowedIPs,
+			Endpoint:                    existing.Endpoint,
+			PersistentKeepaliveInterval: &existing.PersistentKeepaliveInterval,
+			PresharedKey:                &existing.PresharedKey,
+		})
 	}
-	err := r.WgClient.ConfigureDevice(r.WgDevice.Name, cfg)
-	if err != nil {
-		return fmt.Errorf("ConfigureDevice failed: %w", err)
+	if !isDelete {
+		desired = append(desired, wgtypes.PeerConfig{
+			PublicKey:                   peer.PublicKey,
+			AllowedIPs:                  peer.AllowedIPs,
+			Endpoint:                    peer.Endpoint,
+			PersistentKeepaliveInterval: &peer.PersistentKeepaliveInterval,
+			PresharedKey:                &peer.PresharedKey,
+		})
+	}
+
+	if err := wireguard.SyncPeers(r.WgClient, desired); err != nil {
+		return fmt.Errorf("SyncPeers failed: %w", err)
 	}
 	return nil
 }
@@ -163,15 +255,88 @@ func (r *WireguardNodeReconciler) hydrateCache(ctx context.Context) {
 	}
 
 	knownPeers := make(map[string]bool)
-	for _, peer := range r.WgDevice.Peers {
+	for _, peer := range r.device().Peers {
 		knownPeers[peer.PublicKey.String()] = true
 	}
 
-	r.cache = make(map[string]string)
+	r.cache.reset()
 	for _, n := range nodes.Items {
 		publicKey := n.Annotations[wireguard.PublicKeyAnnotationName]
 		if ok := knownPeers[publicKey]; ok && publicKey != "" {
-			r.put(n.Name, publicKey)
+			// The fingerprint is left blank so that the first Reconcile
+			// after a restart always re-applies the peer config; we don't
+			// know what keepalive/PSK was last pushed for it and
+			// ConfigureDevice is idempotent, so this is safe.
+			r.cache.put(n.Name, publicKey, "")
+		}
+	}
+	r.cache.markHydrated()
+}
+
+// Start runs the endpoint discovery loop and implements manager.Runnable so
+// it can be registered on the same manager as the reconciler.
+func (r *WireguardNodeReconciler) Start(ctx context.Context) error {
+	interval := r.DiscoveryInterval
+	if interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.discoverEndpoints(ctx)
+		}
+	}
+}
+
+// discoverEndpoints polls the local device for peers with a recent
+// handshake and republishes the endpoint WireGuard observed them on back
+// onto their owning Node, so that a peer which only knows us through a
+// reflector can eventually be dialed directly.
+func (r *WireguardNodeReconciler) discoverEndpoints(ctx context.Context) {
+	logger := log.FromContext(ctx)
+
+	device, err := r.WgClient.Device()
+	if err != nil {
+		logger.Error(err, "failed to refresh wireguard device for endpoint discovery")
+		return
+	}
+	r.setDevice(device)
+
+	for _, peer := range device.Peers {
+		if peer.Endpoint == nil || !wireguard.HandshakeFresh(peer) {
+			continue
+		}
+
+		nodeName, ok := r.cache.getNode(peer.PublicKey.String())
+		if !ok {
+			continue
+		}
+
+		var node v1.Node
+		if err := r.Get(ctx, client.ObjectKey{Name: nodeName}, &node); err != nil {
+			logger.Error(err, "failed to fetch node for endpoint discovery", "node", nodeName)
+			continue
+		}
+
+		observed := peer.Endpoint.String()
+		if node.Annotations[wireguard.DiscoveredEndpointAnnotation] == observed {
+			continue
+		}
+		if node.Annotations[wireguard.EndpointAnnotationName] == observed {
+			continue
+		}
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[wireguard.DiscoveredEndpointAnnotation] = observed
+		if err := r.Update(ctx, &node); err != nil {
+			logger.Error(err, "failed to publish discovered endpoint", "node", nodeName)
 		}
 	}
 }
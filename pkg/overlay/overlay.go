@@ -0,0 +1,21 @@
+package overlay
+
+import "time"
+
+// Config holds the cluster-wide and host-local settings needed to run the
+// overlay controller. It is embedded directly into WireguardNodeReconciler.
+type Config struct {
+	// NodeName is the name of the Kubernetes Node this process is running on.
+	NodeName string
+	// OverlayIP is the IP address this node advertises inside the overlay.
+	OverlayIP string
+	// DiscoveryInterval controls how often the reconciler polls observed
+	// WireGuard endpoints and republishes them onto peer Nodes.
+	DiscoveryInterval time.Duration
+	// PersistentKeepalive is applied to every peer so NAT mappings are
+	// kept alive. Defaults to wireguard.DefaultPersistentKeepalive if zero.
+	PersistentKeepalive time.Duration
+	// Namespace is the namespace the controller runs in, used to look up
+	// per-node-pair pre-shared key Secrets.
+	Namespace string
+}
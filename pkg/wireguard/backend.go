@@ -0,0 +1,139 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Backend abstracts the WireGuard control-plane operations the reconciler
+// needs, so the kernel module and a userspace implementation can be swapped
+// in behind the same interface.
+type Backend interface {
+	// Device returns the current state of the local WireGuard device.
+	Device() (*wgtypes.Device, error)
+	// ConfigureDevice applies cfg to the local WireGuard device.
+	ConfigureDevice(cfg wgtypes.Config) error
+	// Close releases any resources held by the backend.
+	Close() error
+}
+
+// NewBackend constructs the Backend selected by kind, which is one of
+// "kernel" (the default) or "userspace". userspaceBinary is the path to a
+// wireguard-go or boringtun binary and is required when kind is
+// "userspace".
+func NewBackend(kind, deviceName, userspaceBinary string) (Backend, error) {
+	switch kind {
+	case "", "kernel":
+		return NewKernelBackend(deviceName)
+	case "userspace":
+		if userspaceBinary == "" {
+			return nil, fmt.Errorf("--userspace-binary is required when --wg-backend=userspace")
+		}
+		return NewUserspaceBackend(deviceName, userspaceBinary)
+	default:
+		return nil, fmt.Errorf("unknown wireguard backend %q", kind)
+	}
+}
+
+// KernelBackend talks to a kernel-module WireGuard device via wgctrl.
+type KernelBackend struct {
+	client *wgctrl.Client
+	device string
+}
+
+// NewKernelBackend opens a wgctrl client bound to deviceName.
+func NewKernelBackend(deviceName string) (*KernelBackend, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("creating wgctrl client: %w", err)
+	}
+	return &KernelBackend{client: client, device: deviceName}, nil
+}
+
+func (b *KernelBackend) Device() (*wgtypes.Device, error) {
+	return b.client.Device(b.device)
+}
+
+func (b *KernelBackend) ConfigureDevice(cfg wgtypes.Config) error {
+	return b.client.ConfigureDevice(b.device, cfg)
+}
+
+func (b *KernelBackend) Close() error {
+	return b.client.Close()
+}
+
+// uapiReadyRetries/uapiReadyPollInterval bound how long NewUserspaceBackend
+// waits for a freshly spawned wireguard-go/boringtun process to open its
+// UAPI socket.
+const (
+	uapiReadyRetries      = 50
+	uapiReadyPollInterval = 100 * time.Millisecond
+)
+
+// UserspaceBackend supervises a userspace WireGuard implementation
+// (wireguard-go or boringtun) and talks to it over its UAPI socket via
+// wgctrl, which understands both the kernel and userspace UAPI transports.
+type UserspaceBackend struct {
+	cmd    *exec.Cmd
+	client *wgctrl.Client
+	device string
+}
+
+// NewUserspaceBackend spawns binaryPath as a subprocess to bring up
+// deviceName and waits for its UAPI socket to become available.
+func NewUserspaceBackend(deviceName, binaryPath string) (*UserspaceBackend, error) {
+	cmd := exec.Command(binaryPath, deviceName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting userspace wireguard binary %s: %w", binaryPath, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("creating wgctrl client: %w", err)
+	}
+
+	var ready bool
+	for i := 0; i < uapiReadyRetries; i++ {
+		if _, err := client.Device(deviceName); err == nil {
+			ready = true
+			break
+		}
+		time.Sleep(uapiReadyPollInterval)
+	}
+	if !ready {
+		_ = client.Close()
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("timed out waiting for userspace wireguard device %s to come up", deviceName)
+	}
+
+	return &UserspaceBackend{cmd: cmd, client: client, device: deviceName}, nil
+}
+
+func (b *UserspaceBackend) Device() (*wgtypes.Device, error) {
+	return b.client.Device(b.device)
+}
+
+func (b *UserspaceBackend) ConfigureDevice(cfg wgtypes.Config) error {
+	return b.client.ConfigureDevice(b.device, cfg)
+}
+
+func (b *UserspaceBackend) Close() error {
+	if err := b.client.Close(); err != nil {
+		return fmt.Errorf("closing wgctrl client: %w", err)
+	}
+	if b.cmd.Process == nil {
+		return nil
+	}
+	if err := b.cmd.Process.Signal(os.Interrupt); err != nil {
+		return fmt.Errorf("stopping userspace wireguard process: %w", err)
+	}
+	return b.cmd.Wait()
+}
@@ -0,0 +1,45 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vishvananda/netlink"
+)
+
+// EnsureKernelInterface creates a kernel WireGuard link named name if one
+// does not already exist. It only applies to the kernel backend: userspace
+// implementations (wireguard-go, boringtun) create their own TUN device
+// when they start.
+func EnsureKernelInterface(name string) error {
+	if _, err := netlink.LinkByName(name); err == nil {
+		return nil
+	}
+	link := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	if err := netlink.LinkAdd(link); err != nil {
+		return fmt.Errorf("creating wireguard interface %s: %w", name, err)
+	}
+	return nil
+}
+
+// AssignAddress adds allowedIP to the interface named name as a /32 and
+// brings the link up.
+func AssignAddress(name, allowedIP string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("looking up interface %s: %w", name, err)
+	}
+
+	addr, err := netlink.ParseAddr(allowedIP + "/32")
+	if err != nil {
+		return fmt.Errorf("parsing allowed ip %q: %w", allowedIP, err)
+	}
+	if err := netlink.AddrAdd(link, addr); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("assigning address %s to %s: %w", allowedIP, name, err)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("bringing up interface %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// LoadOrGeneratePrivateKey reads a WireGuard private key from path. If path
+// does not exist, a new key is generated and persisted there.
+func LoadOrGeneratePrivateKey(path string) (wgtypes.Key, error) {
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		key, err := wgtypes.ParseKey(string(raw))
+		if err != nil {
+			return wgtypes.Key{}, fmt.Errorf("parsing private key at %s: %w", path, err)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return wgtypes.Key{}, fmt.Errorf("reading private key at %s: %w", path, err)
+	}
+
+	key, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return wgtypes.Key{}, fmt.Errorf("generating private key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(key.String()), 0600); err != nil {
+		return wgtypes.Key{}, fmt.Errorf("persisting private key to %s: %w", path, err)
+	}
+	return key, nil
+}
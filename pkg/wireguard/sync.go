@@ -0,0 +1,34 @@
+package wireguard
+
+import "golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+// SyncPeers reconciles the peers configured on backend's device against
+// desired, the full set of peers that should exist. Peers present on the
+// device but missing from desired are removed; peers in desired are
+// added or updated in a single ConfigureDevice call. Both the in-cluster
+// reconciler and external clients such as `wg-overlay connect` can use this
+// to resync their full peer set against the cluster's view of the world.
+func SyncPeers(backend Backend, desired []wgtypes.PeerConfig) error {
+	device, err := backend.Device()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[wgtypes.Key]bool, len(desired))
+	for _, p := range desired {
+		wanted[p.PublicKey] = true
+	}
+
+	cfg := wgtypes.Config{Peers: append([]wgtypes.PeerConfig{}, desired...)}
+	for _, existing := range device.Peers {
+		if wanted[existing.PublicKey] {
+			continue
+		}
+		cfg.Peers = append(cfg.Peers, wgtypes.PeerConfig{
+			PublicKey: existing.PublicKey,
+			Remove:    true,
+		})
+	}
+
+	return backend.ConfigureDevice(cfg)
+}
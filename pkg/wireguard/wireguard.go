@@ -0,0 +1,132 @@
+package wireguard
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const (
+	// IPAnnotationName is the overlay IP address assigned to a node.
+	IPAnnotationName = "wireguard.tyler-lloyd.io/overlay-ip"
+	// PublicKeyAnnotationName is the node's WireGuard public key.
+	PublicKeyAnnotationName = "wireguard.tyler-lloyd.io/public-key"
+	// EndpointAnnotationName is the statically configured endpoint
+	// (host:port) used to reach a node.
+	EndpointAnnotationName = "wireguard.tyler-lloyd.io/endpoint"
+	// DiscoveredEndpointAnnotation is the endpoint WireGuard most recently
+	// observed a node handshaking from. It is republished by the discovery
+	// subsystem and is only consulted when the static endpoint above has
+	// gone stale or is not set.
+	DiscoveredEndpointAnnotation = "wireguard.tyler-lloyd.io/discovered-endpoint"
+	// PresharedKeyAnnotationName names the Secret holding the 32-byte
+	// pre-shared key to use with a node, adding a layer of post-quantum
+	// symmetric-key resistance on top of Curve25519. The Secret is looked
+	// up in the controller's own namespace and must store the raw key
+	// bytes under the "psk" data key.
+	PresharedKeyAnnotationName = "wireguard.tyler-lloyd.io/preshared-key-secret"
+)
+
+// DefaultPersistentKeepalive is the keepalive interval used when none is
+// configured, and the interval HandshakeFresh measures freshness against.
+const DefaultPersistentKeepalive = 25 * time.Second
+
+// HandshakeFresh reports whether p has completed a handshake recently enough
+// that its endpoint can be trusted, i.e. within 3x DefaultPersistentKeepalive.
+// A configured keepalive interval other than the default is not taken into
+// account. A peer that has never handshaked is never fresh.
+func HandshakeFresh(p wgtypes.Peer) bool {
+	if p.LastHandshakeTime.IsZero() {
+		return false
+	}
+	return time.Since(p.LastHandshakeTime) <= 3*DefaultPersistentKeepalive
+}
+
+// FromNode builds a wgtypes.Peer from the WireGuard annotations on a Node.
+// knownPeers is the set of peers currently configured on the local device,
+// keyed by public key; it is used to decide whether the node's statically
+// annotated endpoint is still reachable. It may be nil if that information
+// is unavailable, in which case the static endpoint is always preferred.
+// keepalive is applied as the peer's PersistentKeepaliveInterval so NAT
+// mappings stay alive; pass 0 to leave it unset.
+//
+// The pre-shared key, if any, is not populated here since resolving it
+// requires reading a Secret; callers should set Peer.PresharedKey
+// themselves after looking it up.
+func FromNode(n v1.Node, knownPeers map[wgtypes.Key]wgtypes.Peer, keepalive time.Duration) (*wgtypes.Peer, error) {
+	pubKeyRaw, ok := n.Annotations[PublicKeyAnnotationName]
+	if !ok {
+		return nil, fmt.Errorf("node %s missing %s annotation", n.Name, PublicKeyAnnotationName)
+	}
+	pubKey, err := wgtypes.ParseKey(pubKeyRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key for node %s: %w", n.Name, err)
+	}
+
+	ipRaw, ok := n.Annotations[IPAnnotationName]
+	if !ok {
+		return nil, fmt.Errorf("node %s missing %s annotation", n.Name, IPAnnotationName)
+	}
+	ip := net.ParseIP(ipRaw)
+	if ip == nil {
+		return nil, fmt.Errorf("node %s has invalid overlay ip %q", n.Name, ipRaw)
+	}
+
+	peer := &wgtypes.Peer{
+		PublicKey:                   pubKey,
+		AllowedIPs:                  []net.IPNet{{IP: ip, Mask: net.CIDRMask(32, 32)}},
+		PersistentKeepaliveInterval: keepalive,
+	}
+
+	endpoint, err := resolveEndpoint(n, pubKey, knownPeers)
+	if err != nil {
+		return nil, err
+	}
+	peer.Endpoint = endpoint
+
+	return peer, nil
+}
+
+// resolveEndpoint picks the endpoint to dial for n. The static annotation is
+// used as long as it is still considered reachable; otherwise the endpoint
+// most recently observed by the discovery subsystem is preferred, falling
+// back to the static endpoint if nothing has been discovered yet. When
+// knownPeers is nil there is no handshake data to judge reachability by, so
+// the static endpoint is treated as always reachable and preferred over a
+// discovered one, matching FromNode's documented nil behavior.
+func resolveEndpoint(n v1.Node, pubKey wgtypes.Key, knownPeers map[wgtypes.Key]wgtypes.Peer) (*net.UDPAddr, error) {
+	static, hasStatic := n.Annotations[EndpointAnnotationName]
+	if hasStatic && (knownPeers == nil || HandshakeFresh(knownPeers[pubKey])) {
+		return net.ResolveUDPAddr("udp", static)
+	}
+
+	if discovered, ok := n.Annotations[DiscoveredEndpointAnnotation]; ok {
+		return net.ResolveUDPAddr("udp", discovered)
+	}
+
+	if hasStatic {
+		return net.ResolveUDPAddr("udp", static)
+	}
+
+	return nil, nil
+}
+
+// Fingerprint summarizes everything about a peer that should trigger a
+// ConfigureDevice call if it changed: its public key, allowed IPs,
+// endpoint, pre-shared key, and keepalive interval. The endpoint is
+// included deliberately — it is how a discovered endpoint (see
+// DiscoveredEndpointAnnotation) actually gets pushed to the device, so
+// omitting it would make endpoint discovery a no-op on the data plane.
+func Fingerprint(p *wgtypes.Peer) string {
+	pskSum := sha256.Sum256(p.PresharedKey[:])
+	var endpoint string
+	if p.Endpoint != nil {
+		endpoint = p.Endpoint.String()
+	}
+	return fmt.Sprintf("%s|%v|%s|%x|%s", p.PublicKey.String(), p.AllowedIPs, endpoint, pskSum, p.PersistentKeepaliveInterval)
+}